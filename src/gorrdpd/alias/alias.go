@@ -0,0 +1,48 @@
+// Package alias rewrites a message's Source/Name according to operator
+// configured rules, so metrics can be consolidated or renamed without
+// touching the senders that emit them.
+package alias
+
+import "path"
+
+// Rule matches incoming Source/Name against a pair of globs and, on a
+// match, replaces them with Source/Name here. An empty pattern matches
+// anything; an empty replacement leaves that field unchanged.
+type Rule struct {
+    SourcePattern string
+    NamePattern   string
+    Source        string
+    Name          string
+}
+
+// Apply runs source/name through rules in order and returns the result
+// of the first one that matches, or the original values if none do.
+func Apply(rules []*Rule, source, name string) (string, string) {
+    for _, rule := range rules {
+        if rule.matches(source, name) {
+            if rule.Source != "" {
+                source = rule.Source
+            }
+            if rule.Name != "" {
+                name = rule.Name
+            }
+            break
+        }
+    }
+    return source, name
+}
+
+func (r *Rule) matches(source, name string) bool {
+    return globMatch(r.SourcePattern, source) && globMatch(r.NamePattern, name)
+}
+
+// globMatch treats an empty pattern as "match anything" and a
+// malformed pattern as "match nothing", rather than letting a typo in
+// the config file silently rewrite every metric.
+func globMatch(pattern, value string) bool {
+    if pattern == "" {
+        return true
+    }
+    matched, err := path.Match(pattern, value)
+    return err == nil && matched
+}
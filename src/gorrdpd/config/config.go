@@ -0,0 +1,325 @@
+package config
+
+import (
+    "bufio"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "gorrdpd/alias"
+    "gorrdpd/logger"
+)
+
+const (
+    DEFAULT_CONFIG_PATH      = "gorrdpd.conf"
+    DEFAULT_LISTEN           = ":8126"
+    DEFAULT_DATA_DIR         = "data"
+    DEFAULT_ROOT_DIR         = "."
+    DEFAULT_SEVERITY         = logger.Warning
+    DEFAULT_SLICE_INTERVAL   = 10
+    DEFAULT_WRITE_INTERVAL   = 60
+    DEFAULT_BATCH_WRITES     = false
+    DEFAULT_LOOKUP_DNS       = false
+    DEFAULT_WEB_LISTEN       = ":8127"
+    DEFAULT_LOG_BACKEND      = "console"
+    DEFAULT_SHUTDOWN_TIMEOUT = 5e9 // 5 seconds, in ns
+    DEFAULT_LISTEN_WORKERS   = 0   // 0 means "one per runtime.NumCPU()"
+)
+
+// WriterConfig names one entry of a "[writer]" table: which writer
+// factory to instantiate and the options to pass it. Options values are
+// always strings, since they come straight off the config file; a
+// writer factory is responsible for converting them (e.g. to an int).
+type WriterConfig struct {
+    Name    string
+    Options map[string]interface{}
+}
+
+// Config holds every runtime setting, populated first from the config
+// file and then overridden by command line flags.
+//
+// WriteInterval, LookupDns, Writers and Aliases can change after
+// startup, via a SIGHUP reload, while every listener worker and the
+// dumper goroutine are reading them; liveMutex guards those fields and
+// BatchWrites, which a reload leaves as it is. Reach them through the
+// GetXxx/SetXxx accessors below rather than the bare fields once the
+// process is past initialize(). SliceInterval is also rewritten by a
+// reload, but only from the signal-handling goroutine, which is its
+// only reader after startup. Every other field is only ever set once,
+// during startup, so none of these need locking.
+type Config struct {
+    Listen        string
+    DataDir       string
+    RootDir       string
+    LogLevel      int
+    SliceInterval int
+
+    liveMutex     sync.RWMutex
+    WriteInterval int
+    BatchWrites   bool
+    LookupDns     bool
+    Writers       []*WriterConfig
+    Aliases       []*alias.Rule
+
+    WebListen       string
+    LogBackend      string
+    ShutdownTimeout int64
+    ListenWorkers   int
+    UDPAddress      *net.UDPAddr
+}
+
+func (c *Config) GetWriteInterval() int {
+    c.liveMutex.RLock()
+    defer c.liveMutex.RUnlock()
+    return c.WriteInterval
+}
+
+func (c *Config) SetWriteInterval(v int) {
+    c.liveMutex.Lock()
+    c.WriteInterval = v
+    c.liveMutex.Unlock()
+}
+
+func (c *Config) GetBatchWrites() bool {
+    c.liveMutex.RLock()
+    defer c.liveMutex.RUnlock()
+    return c.BatchWrites
+}
+
+func (c *Config) SetBatchWrites(v bool) {
+    c.liveMutex.Lock()
+    c.BatchWrites = v
+    c.liveMutex.Unlock()
+}
+
+func (c *Config) GetLookupDns() bool {
+    c.liveMutex.RLock()
+    defer c.liveMutex.RUnlock()
+    return c.LookupDns
+}
+
+func (c *Config) SetLookupDns(v bool) {
+    c.liveMutex.Lock()
+    c.LookupDns = v
+    c.liveMutex.Unlock()
+}
+
+func (c *Config) GetWriters() []*WriterConfig {
+    c.liveMutex.RLock()
+    defer c.liveMutex.RUnlock()
+    return c.Writers
+}
+
+func (c *Config) SetWriters(v []*WriterConfig) {
+    c.liveMutex.Lock()
+    c.Writers = v
+    c.liveMutex.Unlock()
+}
+
+func (c *Config) GetAliases() []*alias.Rule {
+    c.liveMutex.RLock()
+    defer c.liveMutex.RUnlock()
+    return c.Aliases
+}
+
+func (c *Config) SetAliases(v []*alias.Rule) {
+    c.liveMutex.Lock()
+    c.Aliases = v
+    c.liveMutex.Unlock()
+}
+
+// newDefaultConfig builds a Config with every field set to its
+// documented default. Global starts out as one of these, and Reload
+// builds a fresh one of these to re-parse the config file into, so a
+// SIGHUP without a "[writer]" or "[alias]" block falls back to the same
+// defaults a cold start would rather than keeping stale entries around.
+func newDefaultConfig() *Config {
+    return &Config{
+        Listen:          DEFAULT_LISTEN,
+        DataDir:         DEFAULT_DATA_DIR,
+        RootDir:         DEFAULT_ROOT_DIR,
+        LogLevel:        int(DEFAULT_SEVERITY),
+        SliceInterval:   DEFAULT_SLICE_INTERVAL,
+        WriteInterval:   DEFAULT_WRITE_INTERVAL,
+        BatchWrites:     DEFAULT_BATCH_WRITES,
+        LookupDns:       DEFAULT_LOOKUP_DNS,
+        WebListen:       DEFAULT_WEB_LISTEN,
+        LogBackend:      DEFAULT_LOG_BACKEND,
+        ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+        ListenWorkers:   DEFAULT_LISTEN_WORKERS,
+        Writers: []*WriterConfig{
+            {Name: "quartiles"},
+            {Name: "count"},
+        },
+    }
+}
+
+// Global is the single process-wide configuration instance. Writers
+// defaults to the historical quartiles+count pair; a config file that
+// declares any "[writer]" block replaces this default outright.
+var Global = newDefaultConfig()
+
+// Reload re-parses path into a fresh Config, starting from the same
+// defaults a cold start would use, without touching Global. Callers
+// (main's SIGHUP handler) diff the result against Global themselves and
+// decide which fields are safe to hot-apply.
+func Reload(path string) (*Config, os.Error) {
+    fresh := newDefaultConfig()
+    if err := fresh.Load(path); err != nil {
+        return nil, err
+    }
+    return fresh, nil
+}
+
+// Load reads settings from the config file at path, leaving defaults in
+// place for anything it does not mention. A missing file is not an
+// error: gorrdpd is expected to run from command-line flags alone.
+//
+// The format is a small subset of TOML: top-level "key = value" lines,
+// plus any number of
+//
+//	[writer]
+//	name = graphite
+//	address = 127.0.0.1:2003
+//
+// blocks, each becoming one entry in Writers (the first such block seen
+// replaces the default Writers list), and any number of
+//
+//	[alias]
+//	match_name = myapp.requests.*
+//	name = myapp.requests
+//
+// blocks, each becoming one entry in Aliases. match_source/match_name
+// are globs (path.Match syntax) matched against an incoming message's
+// Source/Name; source/name give the replacement Source/Name, and one
+// left out keeps the message's own value.
+func (c *Config) Load(path string) os.Error {
+    file, err := os.Open(path, os.O_RDONLY, 0)
+    if err != nil {
+        return nil
+    }
+    defer file.Close()
+
+    sawWriter := false
+    var writer *WriterConfig
+    var rule *alias.Rule
+
+    reader := bufio.NewReader(file)
+    for {
+        line, readErr := reader.ReadString('\n')
+        trimmed := strings.TrimSpace(line)
+
+        switch {
+        case len(trimmed) == 0 || trimmed[0] == '#':
+            // blank or comment
+        case trimmed == "[writer]":
+            if !sawWriter {
+                c.Writers = nil
+                sawWriter = true
+            }
+            writer, rule = &WriterConfig{Options: make(map[string]interface{})}, nil
+            c.Writers = append(c.Writers, writer)
+        case trimmed == "[alias]":
+            writer, rule = nil, &alias.Rule{}
+            c.Aliases = append(c.Aliases, rule)
+        case writer != nil:
+            applyWriterLine(writer, trimmed)
+        case rule != nil:
+            applyAliasLine(rule, trimmed)
+        default:
+            c.applyLine(trimmed)
+        }
+
+        if readErr != nil {
+            break
+        }
+    }
+    return nil
+}
+
+// applyLine parses a single already-trimmed "key = value" line.
+func (c *Config) applyLine(line string) {
+    parts := strings.SplitN(line, "=", 2)
+    if len(parts) != 2 {
+        return
+    }
+    key := strings.TrimSpace(parts[0])
+    value := strings.TrimSpace(parts[1])
+
+    switch key {
+    case "listen":
+        c.Listen = value
+    case "data":
+        c.DataDir = value
+    case "root":
+        c.RootDir = value
+    case "debug":
+        if n, err := strconv.Atoi(value); err == nil {
+            c.LogLevel = n
+        }
+    case "slice":
+        if n, err := strconv.Atoi(value); err == nil {
+            c.SliceInterval = n
+        }
+    case "write":
+        if n, err := strconv.Atoi(value); err == nil {
+            c.WriteInterval = n
+        }
+    case "batch":
+        c.BatchWrites = value == "true"
+    case "lookup":
+        c.LookupDns = value == "true"
+    case "web":
+        c.WebListen = value
+    case "logbackend":
+        c.LogBackend = value
+    case "shutdowntimeout":
+        if n, err := strconv.Atoi(value); err == nil {
+            c.ShutdownTimeout = int64(n) * 1e9
+        }
+    case "workers":
+        if n, err := strconv.Atoi(value); err == nil {
+            c.ListenWorkers = n
+        }
+    }
+}
+
+// applyWriterLine parses a single already-trimmed "key = value" line
+// inside a "[writer]" block into writer's Name or Options.
+func applyWriterLine(writer *WriterConfig, line string) {
+    parts := strings.SplitN(line, "=", 2)
+    if len(parts) != 2 {
+        return
+    }
+    key := strings.TrimSpace(parts[0])
+    value := strings.TrimSpace(parts[1])
+
+    if key == "name" {
+        writer.Name = value
+    } else {
+        writer.Options[key] = value
+    }
+}
+
+// applyAliasLine parses a single already-trimmed "key = value" line
+// inside an "[alias]" block into rule's match patterns or replacements.
+func applyAliasLine(rule *alias.Rule, line string) {
+    parts := strings.SplitN(line, "=", 2)
+    if len(parts) != 2 {
+        return
+    }
+    key := strings.TrimSpace(parts[0])
+    value := strings.TrimSpace(parts[1])
+
+    switch key {
+    case "match_source":
+        rule.SourcePattern = value
+    case "match_name":
+        rule.NamePattern = value
+    case "source":
+        rule.Source = value
+    case "name":
+        rule.Name = value
+    }
+}
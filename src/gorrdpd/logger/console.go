@@ -0,0 +1,18 @@
+package logger
+
+import "fmt"
+
+// consoleBackend writes plain-text lines to stdout; the default.
+type consoleBackend struct{}
+
+func (consoleBackend) Write(e *Entry) {
+    fmt.Printf("[%s] [%s] %s%s\n", severityTag(e.Level), e.Subsystem, e.Message, formatFields(e.Fields))
+}
+
+func formatFields(fields map[string]interface{}) string {
+    out := ""
+    for key, value := range fields {
+        out += fmt.Sprintf(" %s=%v", key, value)
+    }
+    return out
+}
@@ -0,0 +1,30 @@
+package logger
+
+import (
+    "json"
+    "os"
+)
+
+// jsonBackend writes one JSON object per line to stdout.
+type jsonBackend struct{}
+
+type jsonEntry struct {
+    Level     string                 "level"
+    Subsystem string                 "subsystem"
+    Message   string                 "message"
+    Fields    map[string]interface{} "fields"
+}
+
+func (jsonBackend) Write(e *Entry) {
+    encoded, err := json.Marshal(jsonEntry{
+        Level:     severityTag(e.Level),
+        Subsystem: e.Subsystem,
+        Message:   e.Message,
+        Fields:    e.Fields,
+    })
+    if err != nil {
+        return
+    }
+    os.Stdout.Write(encoded)
+    os.Stdout.Write([]uint8("\n"))
+}
@@ -0,0 +1,143 @@
+package logger
+
+import (
+    "fmt"
+    "os"
+    "strings"
+)
+
+// Severity controls how verbose a Logger is; lower values are more
+// verbose, matching the -debug 0..5 flag.
+type Severity int
+
+const (
+    Debug Severity = iota
+    Info
+    Warning
+    Error
+    Fatal
+)
+
+// Logger is implemented by every sub-logger handed out by For.
+type Logger interface {
+    Debug(format string, args ...interface{})
+    Info(format string, args ...interface{})
+    Warn(format string, args ...interface{})
+    Fatal(format string, args ...interface{})
+    Infow(message string, keysAndValues ...interface{})
+}
+
+// Entry is a single log record, handed to a Backend for rendering.
+type Entry struct {
+    Level     Severity
+    Subsystem string
+    Message   string
+    Fields    map[string]interface{}
+}
+
+// Backend renders Entries to their destination (console, JSON, syslog).
+type Backend interface {
+    Write(entry *Entry)
+}
+
+var (
+    defaultSeverity = Warning
+    defaultBackend  Backend = consoleBackend{}
+    tracedNames             = parseTrace(os.Getenv("GORRDPD_TRACE"))
+)
+
+func parseTrace(value string) map[string]bool {
+    names := make(map[string]bool)
+    for _, name := range strings.Split(value, ",") {
+        name = strings.TrimSpace(name)
+        if name != "" {
+            names[name] = true
+        }
+    }
+    return names
+}
+
+// SetSeverity sets the default cutoff used by sub-loggers that aren't
+// named in GORRDPD_TRACE. Called once from initialize() after the
+// config file and flags have been parsed.
+func SetSeverity(s Severity) {
+    defaultSeverity = s
+}
+
+// SetBackend sets the Backend new Entries are rendered with.
+func SetBackend(b Backend) {
+    defaultBackend = b
+}
+
+// For returns a Logger scoped to the named subsystem, e.g. "listen" or
+// "dumper". A subsystem listed in GORRDPD_TRACE (or the special name
+// "all") logs everything regardless of the configured severity; this
+// lets one goroutine be diagnosed in full without drowning in the rest.
+func For(name string) Logger {
+    return &subLogger{name: name, traced: tracedNames["all"] || tracedNames[name]}
+}
+
+type subLogger struct {
+    name   string
+    traced bool
+}
+
+func (l *subLogger) enabled(level Severity) bool {
+    return l.traced || level >= defaultSeverity
+}
+
+func (l *subLogger) log(level Severity, format string, args []interface{}) {
+    if !l.enabled(level) {
+        return
+    }
+    defaultBackend.Write(&Entry{Level: level, Subsystem: l.name, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *subLogger) Debug(format string, args ...interface{}) { l.log(Debug, format, args) }
+func (l *subLogger) Info(format string, args ...interface{})  { l.log(Info, format, args) }
+func (l *subLogger) Warn(format string, args ...interface{})  { l.log(Warning, format, args) }
+func (l *subLogger) Fatal(format string, args ...interface{}) { l.log(Fatal, format, args) }
+
+// Infow logs message with structured key/value fields, e.g.
+// log.Infow("received", "src", addr, "bytes", n).
+func (l *subLogger) Infow(message string, keysAndValues ...interface{}) {
+    if !l.enabled(Info) {
+        return
+    }
+
+    fields := make(map[string]interface{})
+    for i := 0; i+1 < len(keysAndValues); i += 2 {
+        key, _ := keysAndValues[i].(string)
+        fields[key] = keysAndValues[i+1]
+    }
+    defaultBackend.Write(&Entry{Level: Info, Subsystem: l.name, Message: message, Fields: fields})
+}
+
+// NewBackend resolves a config-file backend name ("console", "json" or
+// "syslog") to a Backend, falling back to console for anything else.
+func NewBackend(name string) Backend {
+    switch name {
+    case "json":
+        return jsonBackend{}
+    case "syslog":
+        return NewSyslogBackend("gorrdpd")
+    default:
+        return consoleBackend{}
+    }
+}
+
+func severityTag(s Severity) string {
+    switch s {
+    case Debug:
+        return "DEBG"
+    case Info:
+        return "INFO"
+    case Warning:
+        return "WARN"
+    case Error:
+        return "ERRO"
+    case Fatal:
+        return "FATL"
+    }
+    return "????"
+}
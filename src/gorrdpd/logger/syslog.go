@@ -0,0 +1,44 @@
+package logger
+
+import (
+    "fmt"
+    "net"
+    "os"
+)
+
+// syslogBackend forwards entries to a local syslogd over UDP using
+// RFC 3164 framing, facility "user".
+type syslogBackend struct {
+    conn net.Conn
+    tag  string
+}
+
+func NewSyslogBackend(tag string) Backend {
+    conn, _ := net.Dial("udp", "", "127.0.0.1:514")
+    return &syslogBackend{conn: conn, tag: tag}
+}
+
+func (b *syslogBackend) Write(e *Entry) {
+    if b.conn == nil {
+        return
+    }
+    priority := 1*8 + syslogSeverity(e.Level)
+    line := fmt.Sprintf("<%d>%s[%d]: [%s] %s%s", priority, b.tag, os.Getpid(), e.Subsystem, e.Message, formatFields(e.Fields))
+    b.conn.Write([]uint8(line))
+}
+
+func syslogSeverity(s Severity) int {
+    switch s {
+    case Debug:
+        return 7
+    case Info:
+        return 6
+    case Warning:
+        return 4
+    case Error:
+        return 3
+    case Fatal:
+        return 2
+    }
+    return 6
+}
@@ -8,10 +8,15 @@ import (
     "os/signal"
     "path"
     "runtime"
+    "sync"
+    "sync/atomic"
     "time"
+    "gorrdpd/alias"
     "gorrdpd/config"
     "gorrdpd/logger"
+    "gorrdpd/netutil"
     "gorrdpd/parser"
+    "gorrdpd/shutdown"
     "gorrdpd/writers"
     "gorrdpd/stdlib"
     "gorrdpd/types"
@@ -20,56 +25,211 @@ import (
 
 var (
     log                   logger.Logger     /* Logger instance */
+    configPath            string            /* Path the config file was loaded from, for SIGHUP reload */
+    hostLookupMutex       sync.RWMutex      /* Guards hostLookupCache */
     hostLookupCache       map[string]string /* DNS names cache */
     slices                *types.Slices     /* Slices */
-    messagesReceived      int64             /* Messages received */
-    totalMessagesReceived int64             /* Total messages received */
-    bytesReceived         int64             /* Bytes sent */
-    totalBytesReceived    int64             /* Total bytes sent */
+    activeWriters         writerSet         /* Writers currently in use, swappable on reload */
+    messagesReceived      int64             /* Messages received, atomic */
+    totalMessagesReceived int64             /* Total messages received, atomic */
+    bytesReceived         int64             /* Bytes sent, atomic */
+    totalBytesReceived    int64             /* Total bytes sent, atomic */
 )
 
+// writerSet guards the active writer list so a config reload can swap
+// it out from under the dumper goroutine and the final rollup-on-
+// shutdown Closer without either of them racing on a plain slice.
+type writerSet struct {
+    mutex sync.RWMutex
+    list  []writers.Writer
+}
+
+func (w *writerSet) Get() []writers.Writer {
+    w.mutex.RLock()
+    defer w.mutex.RUnlock()
+    return w.list
+}
+
+func (w *writerSet) Set(list []writers.Writer) {
+    w.mutex.Lock()
+    w.list = list
+    w.mutex.Unlock()
+}
+
 func main() {
     // Initialize gorrdpd
     initialize()
 
-    // Quit channel. Should be blocking (non-bufferred), so sender
-    // will wait till receiver will accept message (and shut down)
-    quit := make(chan bool)
+    // Shutdown coordinator: every background goroutine registers its
+    // own Closer here instead of the caller hard-coding how many
+    // "quit <- true" sends to make, so adding a goroutine can no longer
+    // silently deadlock shutdown.
+    manager := shutdown.NewManager(config.Global.ShutdownTimeout)
 
-    // Active writers
-    active_writers := []writers.Writer{
-        &writers.Quartiles{},
-        &writers.Count{},
+    // Active writers, built from the [writer] blocks in the config file
+    built, error := writers.BuildAll(config.Global.GetWriters())
+    if error != nil {
+        log.Fatal("Cannot initialize writers: %s", error)
+        os.Exit(1)
     }
+    activeWriters.Set(built)
 
     // Start background Go routines
-    go listen(quit)
-    go stats()
-    go dumper(active_writers, quit)
-    go web.Start()
+    manager.Register(startListen())
+    manager.Register(startStats())
+    manager.Register(startDumper())
+    manager.Register(startWeb())
+
+    // Run last, so a late-arriving signal still flushes any slices that
+    // were still open when every other Closer had finished
+    manager.Register(shutdown.CloserFunc(func() os.Error {
+        rollupSlices(log, activeWriters.Get(), true)
+        return nil
+    }))
 
     // Handle signals
     for sig := range signal.Incoming {
         var usig = sig.(os.UnixSignal)
         if usig == os.SIGHUP || usig == os.SIGINT || usig == os.SIGTERM {
             log.Warn("Received signal: %s", sig)
-            if usig == os.SIGINT || usig == os.SIGTERM {
-                log.Warn("Shutting down everything...")
-                // We have two background processes, so wait for both
-                quit <- true
-                quit <- true
+            if usig == os.SIGHUP {
+                reloadConfig(log)
             }
-            rollupSlices(active_writers, true)
             if usig == os.SIGINT || usig == os.SIGTERM {
+                log.Warn("Shutting down everything...")
+                manager.Shutdown(log)
                 return
             }
+            rollupSlices(log, activeWriters.Get(), true)
+        }
+    }
+}
+
+// reloadConfig re-reads configPath and hot-applies whatever changed to
+// SliceInterval, WriteInterval, LookupDns, the writer set and the alias
+// rules. It never touches Listen, DataDir, RootDir or ListenWorkers,
+// which only take effect at startup; rebinding those live would require
+// tearing down the listener pool, which is out of scope for a SIGHUP.
+func reloadConfig(log logger.Logger) {
+    fresh, err := config.Reload(configPath)
+    if err != nil {
+        log.Warn("Cannot reload config from %s: %s", configPath, err)
+        return
+    }
+
+    if fresh.SliceInterval != config.Global.SliceInterval {
+        log.Warn("Reload: slice interval %d -> %d", config.Global.SliceInterval, fresh.SliceInterval)
+        config.Global.SliceInterval = fresh.SliceInterval
+        slices.SetInterval(fresh.SliceInterval)
+    }
+    if fresh.WriteInterval != config.Global.GetWriteInterval() {
+        log.Warn("Reload: write interval %d -> %d", config.Global.GetWriteInterval(), fresh.WriteInterval)
+        config.Global.SetWriteInterval(fresh.WriteInterval)
+    }
+    if fresh.LookupDns != config.Global.GetLookupDns() {
+        log.Warn("Reload: lookup dns %t -> %t", config.Global.GetLookupDns(), fresh.LookupDns)
+        config.Global.SetLookupDns(fresh.LookupDns)
+    }
+
+    if writerConfigsEqual(fresh.Writers, config.Global.GetWriters()) {
+        log.Debug("Reload: writer config unchanged, keeping existing writers")
+    } else if built, err := writers.BuildAll(fresh.Writers); err != nil {
+        log.Warn("Reload: keeping existing writers, cannot build %s", err)
+    } else {
+        previous := activeWriters.Get()
+        config.Global.SetWriters(fresh.Writers)
+        activeWriters.Set(built)
+        writers.CloseAll(previous)
+        log.Warn("Reload: writer set replaced (%d writers)", len(built))
+    }
+
+    config.Global.SetAliases(fresh.Aliases)
+}
+
+// writerConfigsEqual reports whether a and b would build the same
+// writer set, so reloadConfig can skip rebuilding (and closing the
+// still-good writers it already has) when the config file's [writer]
+// blocks haven't actually changed.
+func writerConfigsEqual(a, b []*config.WriterConfig) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i, wa := range a {
+        wb := b[i]
+        if wa.Name != wb.Name || len(wa.Options) != len(wb.Options) {
+            return false
+        }
+        for key, value := range wa.Options {
+            if wb.Options[key] != value {
+                return false
+            }
         }
     }
+    return true
+}
+
+// quitCloser adapts the quit/done channel pair used by the listen,
+// stats and dumper goroutines to shutdown.Closer.
+type quitCloser struct {
+    quit chan bool
+    done chan bool
+}
+
+func (c *quitCloser) Close() os.Error {
+    c.quit <- true
+    <-c.done
+    return nil
+}
+
+// startListen launches one listener worker per configured core, each
+// with its own SO_REUSEPORT socket, and returns a Closer that signals
+// all of them to stop and waits for them to drain.
+func startListen() shutdown.Closer {
+    workers := config.Global.ListenWorkers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+
+    quit := make(chan bool)
+    var wg sync.WaitGroup
+    wg.Add(workers)
+    for i := 0; i < workers; i++ {
+        go func(id int) {
+            defer wg.Done()
+            listen(id, quit)
+        }(i)
+    }
+
+    return shutdown.CloserFunc(func() os.Error {
+        close(quit)
+        wg.Wait()
+        return nil
+    })
+}
+
+func startStats() shutdown.Closer {
+    quit, done := make(chan bool), make(chan bool)
+    go stats(quit, done)
+    return &quitCloser{quit: quit, done: done}
+}
+
+func startDumper() shutdown.Closer {
+    quit, done := make(chan bool), make(chan bool)
+    go dumper(quit, done)
+    return &quitCloser{quit: quit, done: done}
+}
+
+// startWeb launches the HTTP status server. Its underlying listener has
+// no graceful-shutdown hook, so its Closer is a no-op; it is still
+// registered so web.Start is visible in the shutdown sequence.
+func startWeb() shutdown.Closer {
+    go web.Start()
+    return shutdown.CloserFunc(func() os.Error { return nil })
 }
 
 func initialize() {
     // Initialize options parser
-    var slice, write, debug int
+    var slice, write, debug, workers int
     var listen, data, root, cfg string
     var test, batch, lookup bool
     flag.StringVar(&cfg, "config", config.DEFAULT_CONFIG_PATH, "Set the path to config file")
@@ -79,6 +239,7 @@ func initialize() {
     flag.IntVar(&debug, "debug", int(config.DEFAULT_SEVERITY), "Set the debug level, the lower - the more verbose (0-5)")
     flag.IntVar(&slice, "slice", config.DEFAULT_SLICE_INTERVAL, "Set the slice interval in seconds")
     flag.IntVar(&write, "write", config.DEFAULT_WRITE_INTERVAL, "Set the write interval in seconds")
+    flag.IntVar(&workers, "workers", config.DEFAULT_LISTEN_WORKERS, "Set the number of UDP listener workers (0 = runtime.NumCPU())")
     flag.BoolVar(&batch, "batch", config.DEFAULT_BATCH_WRITES, "Set the value indicating whether batch RRD updates should be used")
     flag.BoolVar(&lookup, "lookup", config.DEFAULT_LOOKUP_DNS, "Set the value indicating whether reverse DNS lookup should be performed for sources")
     flag.BoolVar(&test, "test", false, "Validate config file and exit")
@@ -93,6 +254,7 @@ func initialize() {
         cfg = path.Join(binaryRoot, cfg)
     }
     // Load config from a config file
+    configPath = cfg
     config.Global.Load(cfg)
     if test {
         os.Exit(0)
@@ -116,13 +278,16 @@ func initialize() {
         config.Global.SliceInterval = slice
     }
     if write != config.DEFAULT_WRITE_INTERVAL {
-        config.Global.WriteInterval = write
+        config.Global.SetWriteInterval(write)
     }
     if batch != config.DEFAULT_BATCH_WRITES {
-        config.Global.BatchWrites = batch
+        config.Global.SetBatchWrites(batch)
     }
     if lookup != config.DEFAULT_LOOKUP_DNS {
-        config.Global.LookupDns = lookup
+        config.Global.SetLookupDns(lookup)
+    }
+    if workers != config.DEFAULT_LISTEN_WORKERS {
+        config.Global.ListenWorkers = workers
     }
 
     // Make data dir path absolute
@@ -135,9 +300,12 @@ func initialize() {
         config.Global.RootDir = path.Join(binaryRoot, config.Global.RootDir)
     }
 
-    // Create logger
-    config.Global.Logger = logger.NewConsoleLogger(logger.Severity(config.Global.LogLevel))
-    log = config.Global.Logger
+    // Configure logging: a global severity cutoff plus the backend
+    // chosen in the config file. GORRDPD_TRACE=listen,dumper (or "all")
+    // additionally forces full verbosity for the named subsystems.
+    logger.SetSeverity(logger.Severity(config.Global.LogLevel))
+    logger.SetBackend(logger.NewBackend(config.Global.LogBackend))
+    log = logger.For("main")
     log.Debug("%s", config.Global)
 
     // Ensure data directory exists
@@ -156,10 +324,10 @@ func initialize() {
     // Initialize slices structure
     slices = types.NewSlices(config.Global.SliceInterval)
 
-    // Initialize host lookup cache
-    if config.Global.LookupDns {
-        hostLookupCache = make(map[string]string)
-    }
+    // Initialize host lookup cache. Allocated unconditionally (even if
+    // lookup is currently off) so a SIGHUP reload can turn LookupDns on
+    // later without lookupHost hitting a nil map.
+    hostLookupCache = make(map[string]string)
 
     // Disable memory profiling to prevent panics reporting
     runtime.MemProfileRate = 0
@@ -167,11 +335,15 @@ func initialize() {
 
 /***** Go routines ************************************************************/
 
-func listen(quit chan bool) {
-    log.Debug("Starting listener on %s", config.Global.UDPAddress)
+// listen runs one worker of the listener pool: its own socket (bound
+// with SO_REUSEPORT so the kernel spreads datagrams across every
+// worker), its own read buffer, and no locking on the hot path beyond
+// what Slices.Add itself stripes across.
+func listen(id int, quit chan bool) {
+    log := logger.For("listen")
+    log.Debug("Starting listener %d on %s", id, config.Global.UDPAddress)
 
-    // Listen for requests
-    listener, error := net.ListenUDP("udp", config.Global.UDPAddress)
+    listener, error := netutil.ListenUDPReusePort(config.Global.UDPAddress)
     if error != nil {
         log.Fatal("Cannot listen: %s", error)
         os.Exit(1)
@@ -183,11 +355,13 @@ func listen(quit chan bool) {
     listener.SetTimeout(100000000)
     listener.SetReadTimeout(100000000)
 
-    message := make([]byte, 256)
+    // Sized for jumbo StatsD packets rather than the original
+    // protocol's much smaller lines.
+    message := make([]byte, 8192)
     for {
         select {
         case <-quit:
-            log.Debug("Shutting down listener...")
+            log.Debug("Shutting down listener %d...", id)
             return
         default:
             n, addr, error := listener.ReadFromUDP(message)
@@ -197,71 +371,99 @@ func listen(quit chan bool) {
                 }
                 continue
             }
-            process(addr, string(message[0:n]))
+            log.Infow("received", "worker", id, "src", addr, "bytes", n)
+            process(log, addr, message[0:n])
         }
     }
 }
 
-func stats() {
+func stats(quit, done chan bool) {
+    log := logger.For("stats")
     ticker := time.NewTicker(1000000000)
     defer ticker.Stop()
 
     for {
-        <-ticker.C
-        slices.Add(types.NewMessage("all", "gorrdpd$messages_count", int(messagesReceived)))
-        slices.Add(types.NewMessage("all", "gorrdpd$traffic_in", int(bytesReceived)))
-        slices.Add(types.NewMessage("all", "gorrdpd$memory_used", int(runtime.MemStats.Alloc/1024)))
-        slices.Add(types.NewMessage("all", "gorrdpd$memory_system", int(runtime.MemStats.Sys/1024)))
+        select {
+        case <-quit:
+            log.Debug("Shutting down stats...")
+            done <- true
+            return
+        case <-ticker.C:
+            slices.Add(types.NewMessage("all", "gorrdpd$messages_count", int(atomic.LoadInt64(&messagesReceived))))
+            slices.Add(types.NewMessage("all", "gorrdpd$traffic_in", int(atomic.LoadInt64(&bytesReceived))))
+            slices.Add(types.NewMessage("all", "gorrdpd$memory_used", int(runtime.MemStats.Alloc/1024)))
+            slices.Add(types.NewMessage("all", "gorrdpd$memory_system", int(runtime.MemStats.Sys/1024)))
 
-        messagesReceived = 0
-        bytesReceived = 0
+            atomic.StoreInt64(&messagesReceived, 0)
+            atomic.StoreInt64(&bytesReceived, 0)
+        }
     }
 }
 
-func dumper(active_writers []writers.Writer, quit chan bool) {
-    ticker := time.NewTicker(int64(config.Global.WriteInterval) * 1000000000)
+// dumper ticks once a second rather than once per WriteInterval so that
+// a SIGHUP changing WriteInterval takes effect on its next tick instead
+// of waiting out whatever period was in force when the ticker started.
+func dumper(quit, done chan bool) {
+    log := logger.For("dumper")
+    ticker := time.NewTicker(1000000000)
     defer ticker.Stop()
 
+    lastWrite := time.Seconds()
     for {
         select {
         case <-quit:
             log.Debug("Shutting down dumper...")
+            done <- true
             return
-        case <-ticker.C:
-            rollupSlices(active_writers, false)
+        case now := <-ticker.C:
+            seconds := now / 1000000000
+            if seconds-lastWrite >= int64(config.Global.GetWriteInterval()) {
+                rollupSlices(log, activeWriters.Get(), false)
+                lastWrite = seconds
+            }
         }
     }
 }
 
 /***** Helper functions *******************************************************/
 
-func process(addr *net.UDPAddr, buf string) {
+// process parses a single packet straight off the listener worker's
+// read buffer; buf is only valid until the caller reuses it, so
+// everything retained past this call (message.Source, message.Name)
+// must already have been copied out by the parser.
+func process(log logger.Logger, addr *net.UDPAddr, buf []byte) {
     log.Debug("Processing message from %s: %s", addr, buf)
-    bytesReceived += int64(len(buf))
-    totalBytesReceived += int64(len(buf))
-    parser.Parse(buf, func(message *types.Message, err os.Error) {
+    atomic.AddInt64(&bytesReceived, int64(len(buf)))
+    atomic.AddInt64(&totalBytesReceived, int64(len(buf)))
+    parser.ParseBytes(buf, func(message *types.Message, err os.Error) {
         if err == nil {
             if message.Source == "" {
-                message.Source = lookupHost(addr)
+                message.Source = lookupHost(log, addr)
             }
+            message.Source, message.Name = alias.Apply(config.Global.GetAliases(), message.Source, message.Name)
             slices.Add(message)
-            messagesReceived++
-            totalMessagesReceived++
+            atomic.AddInt64(&messagesReceived, 1)
+            atomic.AddInt64(&totalMessagesReceived, 1)
         } else {
             log.Debug("Error while parsing a message: %s", err)
         }
     })
 }
 
-func lookupHost(addr *net.UDPAddr) (hostname string) {
+// lookupHost is called concurrently by every listener worker, so
+// hostLookupCache is guarded by hostLookupMutex rather than left as a
+// plain map.
+func lookupHost(log logger.Logger, addr *net.UDPAddr) (hostname string) {
     ip := addr.IP.String()
-    if !config.Global.LookupDns {
+    if !config.Global.GetLookupDns() {
         return ip
     }
 
-    // Do we have resolved this address before?
-    if _, found := hostLookupCache[ip]; found {
-        return hostLookupCache[ip]
+    hostLookupMutex.RLock()
+    cached, found := hostLookupCache[ip]
+    hostLookupMutex.RUnlock()
+    if found {
+        return cached
     }
 
     // Try to lookup
@@ -270,16 +472,18 @@ func lookupHost(addr *net.UDPAddr) (hostname string) {
         log.Debug("Error while resolving host name %s: %s", addr, error)
         return ip
     }
-    // Cache the lookup result
+
+    hostLookupMutex.Lock()
     hostLookupCache[ip] = hostname
+    hostLookupMutex.Unlock()
 
     return
 }
 
-func rollupSlices(active_writers []writers.Writer, force bool) {
+func rollupSlices(log logger.Logger, active_writers []writers.Writer, force bool) {
     log.Debug("Rolling up slices")
 
-    if config.Global.BatchWrites {
+    if config.Global.GetBatchWrites() {
         closedSampleSets := slices.ExtractClosedSampleSets(force)
         for _, writer := range active_writers {
             writers.BatchRollup(writer, closedSampleSets)
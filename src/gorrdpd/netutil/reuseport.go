@@ -0,0 +1,63 @@
+// Package netutil holds small networking helpers that the standard net
+// package does not expose directly.
+package netutil
+
+import (
+    "net"
+    "os"
+    "syscall"
+)
+
+// ListenUDPReusePort opens a UDP socket bound to addr with SO_REUSEPORT
+// set, so the listener pool can run one goroutine per core, each with
+// its own socket, and let the kernel load-balance incoming datagrams
+// across them instead of funneling everything through a single fd.
+//
+// addr.IP picks the socket family: a nil or IPv4 address binds an
+// AF_INET socket same as the net.ListenUDP call this replaced, and an
+// IPv6 address binds AF_INET6 instead of silently falling back to the
+// IPv4 wildcard.
+func ListenUDPReusePort(addr *net.UDPAddr) (*net.UDPConn, os.Error) {
+    ipv6 := addr.IP != nil && addr.IP.To4() == nil
+
+    family := syscall.AF_INET
+    if ipv6 {
+        family = syscall.AF_INET6
+    }
+
+    fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+    if err != 0 {
+        return nil, os.NewSyscallError("socket", err)
+    }
+
+    if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1); err != 0 {
+        syscall.Close(fd)
+        return nil, os.NewSyscallError("setsockopt", err)
+    }
+
+    if ipv6 {
+        sa := &syscall.SockaddrInet6{Port: addr.Port}
+        copy(sa.Addr[:], addr.IP.To16())
+        if err := syscall.Bind(fd, sa); err != 0 {
+            syscall.Close(fd)
+            return nil, os.NewSyscallError("bind", err)
+        }
+    } else {
+        sa := &syscall.SockaddrInet4{Port: addr.Port}
+        if ip := addr.IP.To4(); ip != nil {
+            copy(sa.Addr[:], ip)
+        }
+        if err := syscall.Bind(fd, sa); err != 0 {
+            syscall.Close(fd)
+            return nil, os.NewSyscallError("bind", err)
+        }
+    }
+
+    file := os.NewFile(fd, "reuseport")
+    conn, fcErr := net.FileConn(file)
+    file.Close()
+    if fcErr != nil {
+        return nil, fcErr
+    }
+    return conn.(*net.UDPConn), nil
+}
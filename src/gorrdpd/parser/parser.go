@@ -0,0 +1,107 @@
+package parser
+
+import (
+    "bytes"
+    "os"
+    "strconv"
+    "gorrdpd/types"
+)
+
+var (
+    newline = []byte("\n")
+    pipe    = []byte("|")
+    colon   = []byte(":")
+    at      = []byte("@")
+)
+
+// Parse splits a UDP packet into one or more metric samples and invokes
+// callback once per sample. It is a thin wrapper around ParseBytes for
+// callers that already have a string; listen's worker goroutines call
+// ParseBytes directly so a packet's bytes never need to be copied into
+// a string first.
+func Parse(buf string, callback func(message *types.Message, err os.Error)) {
+    ParseBytes([]byte(buf), callback)
+}
+
+// ParseBytes is Parse's []byte-based counterpart. Two wire formats are
+// understood, detected per line, so plain gorrdpd senders and StatsD
+// clients (e.g. g2s) can share the same listener and port:
+//
+//   - the original gorrdpd protocol: "name value"
+//   - the StatsD protocol: "bucket:value|type[|@sampleRate]", where
+//     type is "c" (counter), "ms" (timer), "g" (gauge) or "s" (set)
+//
+// In both cases message.Source is left blank; process() fills it in
+// from the sender's address when the protocol itself doesn't name one.
+func ParseBytes(buf []byte, callback func(message *types.Message, err os.Error)) {
+    for _, line := range bytes.Split(buf, newline) {
+        line = bytes.TrimRight(line, "\r")
+        if len(line) == 0 {
+            continue
+        }
+        if bytes.Contains(line, pipe) {
+            parseStatsd(line, callback)
+        } else {
+            parseGorrdpd(line, callback)
+        }
+    }
+}
+
+func parseGorrdpd(line []byte, callback func(message *types.Message, err os.Error)) {
+    fields := bytes.Fields(line)
+    if len(fields) != 2 {
+        callback(nil, os.NewError("malformed gorrdpd line: "+string(line)))
+        return
+    }
+
+    value, err := strconv.Atoi(string(fields[1]))
+    if err != nil {
+        callback(nil, err)
+        return
+    }
+    callback(types.NewMessage("", string(fields[0]), value), nil)
+}
+
+// parseStatsd handles "bucket:value|type[|@sampleRate]" lines. Counters
+// honor an optional sample rate by scaling the value back up to an
+// estimate of the true count; gauges, timers and sets pass through
+// unchanged.
+func parseStatsd(line []byte, callback func(message *types.Message, err os.Error)) {
+    parts := bytes.Split(line, pipe)
+    if len(parts) < 2 {
+        callback(nil, os.NewError("malformed statsd line: "+string(line)))
+        return
+    }
+
+    nameValue := bytes.SplitN(parts[0], colon, 2)
+    if len(nameValue) != 2 {
+        callback(nil, os.NewError("malformed statsd line: "+string(line)))
+        return
+    }
+
+    value, err := strconv.Atoi(string(nameValue[1]))
+    if err != nil {
+        callback(nil, err)
+        return
+    }
+
+    metricType := string(parts[1])
+    switch metricType {
+    case "c":
+        if len(parts) == 3 && bytes.HasPrefix(parts[2], at) {
+            rate, err := strconv.Atof64(string(parts[2][1:]))
+            if err == nil && rate > 0 {
+                value = int(float64(value) / rate)
+            }
+        }
+    case "ms", "g", "s":
+        // pass through as-is
+    default:
+        callback(nil, os.NewError("unknown statsd type in line: "+string(line)))
+        return
+    }
+
+    message := types.NewMessage("", string(nameValue[0]), value)
+    message.Type = metricType
+    callback(message, nil)
+}
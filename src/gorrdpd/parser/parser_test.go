@@ -0,0 +1,159 @@
+package parser
+
+import (
+    "os"
+    "testing"
+    "gorrdpd/types"
+)
+
+var benchPacket = []byte("foo.bar:1|c\nbaz.qux:42|g\nsome.counter 7\n")
+
+func discard(message *types.Message, err os.Error) {}
+
+// parseOne runs line through ParseBytes and expects exactly one
+// callback, returning what it was given.
+func parseOne(t *testing.T, line string) (*types.Message, os.Error) {
+    var message *types.Message
+    var parseErr os.Error
+    calls := 0
+    ParseBytes([]byte(line), func(m *types.Message, err os.Error) {
+        calls++
+        message = m
+        parseErr = err
+    })
+    if calls != 1 {
+        t.Fatalf("%q: expected exactly one callback, got %d", line, calls)
+    }
+    return message, parseErr
+}
+
+func TestParseBytesGorrdpd(t *testing.T) {
+    message, err := parseOne(t, "some.counter 42")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if message.Source != "" || message.Name != "some.counter" || message.Value != 42 || message.Type != "" {
+        t.Errorf("got %+v", message)
+    }
+}
+
+func TestParseBytesGorrdpdMalformed(t *testing.T) {
+    for _, line := range []string{"onefield", "too many fields here"} {
+        if _, err := parseOne(t, line); err == nil {
+            t.Errorf("%q: expected a malformed-line error, got none", line)
+        }
+    }
+}
+
+func TestParseBytesGorrdpdBadValue(t *testing.T) {
+    if _, err := parseOne(t, "some.counter notanumber"); err == nil {
+        t.Errorf("expected an error for a non-numeric value, got none")
+    }
+}
+
+func TestParseBytesStatsdCounter(t *testing.T) {
+    message, err := parseOne(t, "foo.bar:5|c")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if message.Name != "foo.bar" || message.Value != 5 || message.Type != "c" {
+        t.Errorf("got %+v", message)
+    }
+}
+
+// TestParseBytesStatsdCounterSampleRate checks that a sampled counter
+// is scaled back up to an estimate of the true count.
+func TestParseBytesStatsdCounterSampleRate(t *testing.T) {
+    message, err := parseOne(t, "foo.bar:5|c|@0.5")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if message.Value != 10 {
+        t.Errorf("expected a 0.5 sample rate to scale 5 up to 10, got %d", message.Value)
+    }
+}
+
+func TestParseBytesStatsdGauge(t *testing.T) {
+    message, err := parseOne(t, "foo.bar:7|g")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if message.Value != 7 || message.Type != "g" {
+        t.Errorf("got %+v", message)
+    }
+}
+
+func TestParseBytesStatsdTimer(t *testing.T) {
+    message, err := parseOne(t, "foo.bar:123|ms")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if message.Value != 123 || message.Type != "ms" {
+        t.Errorf("got %+v", message)
+    }
+}
+
+func TestParseBytesStatsdSet(t *testing.T) {
+    message, err := parseOne(t, "foo.bar:1|s")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if message.Value != 1 || message.Type != "s" {
+        t.Errorf("got %+v", message)
+    }
+}
+
+func TestParseBytesStatsdUnknownType(t *testing.T) {
+    if _, err := parseOne(t, "foo.bar:1|zz"); err == nil {
+        t.Errorf("expected an error for an unknown statsd type, got none")
+    }
+}
+
+func TestParseBytesStatsdMalformed(t *testing.T) {
+    for _, line := range []string{"foo.bar|c", "foo.bar:|c"} {
+        if _, err := parseOne(t, line); err == nil {
+            t.Errorf("%q: expected a malformed-line error, got none", line)
+        }
+    }
+}
+
+// TestParseBytesMultiMetricPacket checks that a multi-line packet is
+// split into one callback per line, gorrdpd and statsd lines alike, and
+// in order.
+func TestParseBytesMultiMetricPacket(t *testing.T) {
+    var names []string
+    ParseBytes(benchPacket, func(m *types.Message, err os.Error) {
+        if err != nil {
+            t.Fatalf("unexpected error: %s", err)
+        }
+        names = append(names, m.Name)
+    })
+
+    expected := []string{"foo.bar", "baz.qux", "some.counter"}
+    if len(names) != len(expected) {
+        t.Fatalf("expected %d messages, got %d: %v", len(expected), len(names), names)
+    }
+    for i, name := range expected {
+        if names[i] != name {
+            t.Errorf("message %d: expected %q, got %q", i, name, names[i])
+        }
+    }
+}
+
+// BenchmarkParseBytes measures the listener's hot path: parsing
+// straight off the buffer a worker read from the socket into.
+func BenchmarkParseBytes(b *testing.B) {
+    for i := 0; i < b.N; i++ {
+        ParseBytes(benchPacket, discard)
+    }
+}
+
+// BenchmarkParse measures the string-based wrapper, which pays for one
+// extra copy of the packet per call; the gap between the two is what
+// the concurrent listener avoids by calling ParseBytes directly.
+func BenchmarkParse(b *testing.B) {
+    packet := string(benchPacket)
+    for i := 0; i < b.N; i++ {
+        Parse(packet, discard)
+    }
+}
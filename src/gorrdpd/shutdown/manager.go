@@ -0,0 +1,69 @@
+// Package shutdown coordinates an orderly process exit across an
+// arbitrary number of background goroutines, in the spirit of the
+// Death library: goroutines register a Closer instead of the caller
+// hard-coding how many "quit <- true" sends to make, so a forgotten
+// registration can no longer deadlock shutdown.
+package shutdown
+
+import (
+    "os"
+    "time"
+    "gorrdpd/logger"
+)
+
+// Closer is implemented by anything that must flush or release
+// resources before gorrdpd exits.
+type Closer interface {
+    Close() os.Error
+}
+
+// CloserFunc adapts a plain function to a Closer.
+type CloserFunc func() os.Error
+
+func (f CloserFunc) Close() os.Error {
+    return f()
+}
+
+// Manager fans a single shutdown request out to every registered
+// Closer, in registration order, and gives up waiting after timeout so
+// a stuck Closer can't hang the process forever.
+type Manager struct {
+    closers []Closer
+    timeout int64 // nanoseconds
+}
+
+// NewManager creates a Manager that waits at most timeout nanoseconds
+// for all registered Closers to finish.
+func NewManager(timeout int64) *Manager {
+    return &Manager{timeout: timeout}
+}
+
+// Register adds closer to the list Shutdown will close. Closers run in
+// registration order, so work that must happen last (e.g. a final RRD
+// flush) should register last.
+func (m *Manager) Register(closer Closer) {
+    m.closers = append(m.closers, closer)
+}
+
+// Shutdown closes every registered Closer in order, logging rather than
+// propagating individual failures so one bad Closer doesn't stop the
+// rest from running. It returns once they have all finished or once
+// timeout has elapsed, whichever comes first.
+func (m *Manager) Shutdown(log logger.Logger) {
+    done := make(chan bool)
+    go func() {
+        for _, closer := range m.closers {
+            if err := closer.Close(); err != nil {
+                log.Warn("shutdown: %s", err)
+            }
+        }
+        done <- true
+    }()
+
+    select {
+    case <-done:
+        log.Debug("shutdown: all closers finished")
+    case <-time.After(m.timeout):
+        log.Warn("shutdown: timed out after %dns, exiting anyway", m.timeout)
+    }
+}
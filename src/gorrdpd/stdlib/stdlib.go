@@ -0,0 +1,20 @@
+package stdlib
+
+import (
+    "net"
+    "os"
+)
+
+// GetRemoteHostName resolves ip to a DNS name, used to label metrics by
+// source host when -lookup is enabled. If ip has no PTR record it is
+// returned unchanged.
+func GetRemoteHostName(ip string) (string, os.Error) {
+    names, err := net.LookupAddr(ip)
+    if err != nil {
+        return "", err
+    }
+    if len(names) == 0 {
+        return ip, nil
+    }
+    return names[0], nil
+}
@@ -0,0 +1,178 @@
+package types
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// shardCount is the number of independent locks Slices stripes its
+// index across; Add only ever takes one of these, so concurrent
+// listener workers touching different metrics rarely contend.
+const shardCount = 32
+
+// Message is a single metric sample as decoded by the parser package.
+// Source is the reporting host (blank until the listener fills it in
+// from the sender's address), and Type carries the StatsD metric kind
+// ("c", "g", "ms", "s") or "" for the original gorrdpd protocol.
+type Message struct {
+    Source string
+    Name   string
+    Type   string
+    Value  int
+}
+
+func NewMessage(source, name string, value int) *Message {
+    return &Message{Source: source, Name: name, Value: value}
+}
+
+// SampleSet accumulates every value reported for one source+name pair
+// during a single slice interval.
+type SampleSet struct {
+    Source    string
+    Name      string
+    Type      string
+    Values    []int
+    Timestamp int64
+}
+
+// Slice groups the sample sets collected during one SliceInterval
+// window, bounded by [Start, End).
+type Slice struct {
+    Start int64
+    End   int64
+    Sets  []*SampleSet
+}
+
+// shard is one stripe of the Slices index: its own mutex and its own
+// slice of the current window's sample sets.
+type shard struct {
+    mutex sync.Mutex
+    index map[string]*SampleSet
+}
+
+// Slices is the in-memory ring of open and closed Slice windows that
+// messages are appended to and writers drain from. The open window's
+// boundary is read with atomic loads so Add's hot path only ever takes
+// a single shard's lock; rotating to a new window takes every shard's
+// lock once, which happens at most once per SliceInterval.
+type Slices struct {
+    interval  int64
+    openStart int64 // atomic
+    openEnd   int64 // atomic
+    shards    [shardCount]*shard
+
+    rotateMutex sync.Mutex
+    closedMutex sync.Mutex
+    closed      []*Slice
+}
+
+func NewSlices(interval int) *Slices {
+    s := &Slices{interval: int64(interval)}
+    for i := range s.shards {
+        s.shards[i] = &shard{index: make(map[string]*SampleSet)}
+    }
+
+    now := time.Seconds()
+    s.openStart = now
+    s.openEnd = now + s.interval
+    return s
+}
+
+// SetInterval changes the window length used by future rotations. It
+// takes effect the next time the open window elapses; the window
+// currently open keeps its original End until then, so reconfiguring
+// the interval never drops or truncates in-flight data.
+func (s *Slices) SetInterval(interval int) {
+    atomic.StoreInt64(&s.interval, int64(interval))
+}
+
+// shardFor picks a shard deterministically from a source+name key
+// using FNV-1a, so the same metric always lands on the same shard.
+func shardFor(key string) int {
+    hash := uint32(2166136261)
+    for i := 0; i < len(key); i++ {
+        hash ^= uint32(key[i])
+        hash *= 16777619
+    }
+    return int(hash % shardCount)
+}
+
+// Add records a message against the currently open slice, rotating to
+// a new slice first if the interval has elapsed.
+func (s *Slices) Add(message *Message) {
+    now := time.Seconds()
+    if now >= atomic.LoadInt64(&s.openEnd) {
+        s.rotate(now, false)
+    }
+
+    key := message.Source + "\x00" + message.Name
+    sh := s.shards[shardFor(key)]
+
+    sh.mutex.Lock()
+    set, found := sh.index[key]
+    if !found {
+        set = &SampleSet{Source: message.Source, Name: message.Name, Type: message.Type, Timestamp: atomic.LoadInt64(&s.openStart)}
+        sh.index[key] = set
+    }
+    set.Values = append(set.Values, message.Value)
+    sh.mutex.Unlock()
+}
+
+// rotate closes the current window and opens a new one starting at
+// now. Callers racing to auto-rotate once the window has elapsed rely
+// on the openEnd check below so only the first one actually does the
+// work; force bypasses that check so a shutdown or reload flush closes
+// the still-open window even though it hasn't elapsed yet, instead of
+// silently dropping whatever it holds.
+func (s *Slices) rotate(now int64, force bool) {
+    s.rotateMutex.Lock()
+    defer s.rotateMutex.Unlock()
+
+    if !force && now < atomic.LoadInt64(&s.openEnd) {
+        return // another goroutine already rotated while we waited
+    }
+
+    slice := &Slice{Start: atomic.LoadInt64(&s.openStart), End: atomic.LoadInt64(&s.openEnd)}
+    for _, sh := range s.shards {
+        sh.mutex.Lock()
+        for _, set := range sh.index {
+            slice.Sets = append(slice.Sets, set)
+        }
+        sh.index = make(map[string]*SampleSet)
+        sh.mutex.Unlock()
+    }
+
+    s.closedMutex.Lock()
+    s.closed = append(s.closed, slice)
+    s.closedMutex.Unlock()
+
+    atomic.StoreInt64(&s.openStart, now)
+    atomic.StoreInt64(&s.openEnd, now+atomic.LoadInt64(&s.interval))
+}
+
+// ExtractClosedSlices returns and removes every fully-closed slice.
+// When force is true the currently open slice is rotated out and
+// returned too, which is how shutdown flushes in-flight data.
+func (s *Slices) ExtractClosedSlices(force bool) []*Slice {
+    if force {
+        s.rotate(time.Seconds(), true)
+    }
+
+    s.closedMutex.Lock()
+    defer s.closedMutex.Unlock()
+    closed := s.closed
+    s.closed = nil
+    return closed
+}
+
+// ExtractClosedSampleSets is ExtractClosedSlices flattened to a single
+// list of sample sets, for writers that roll up in batches rather than
+// slice-by-slice.
+func (s *Slices) ExtractClosedSampleSets(force bool) []*SampleSet {
+    var sets []*SampleSet
+    for _, slice := range s.ExtractClosedSlices(force) {
+        sets = append(sets, slice.Sets...)
+    }
+    return sets
+}
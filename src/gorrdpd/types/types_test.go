@@ -0,0 +1,35 @@
+package types
+
+import (
+    "fmt"
+    "sync/atomic"
+    "testing"
+)
+
+// BenchmarkSlicesAdd measures Add from a single goroutine, as a
+// baseline for BenchmarkSlicesAddParallel below.
+func BenchmarkSlicesAdd(b *testing.B) {
+    s := NewSlices(60)
+    message := NewMessage("host1", "some.counter", 1)
+    for i := 0; i < b.N; i++ {
+        s.Add(message)
+    }
+}
+
+// BenchmarkSlicesAddParallel drives Add the way the concurrent listener
+// pool actually does: one goroutine per worker, each reporting for its
+// own source, so most calls land on different shards and only
+// occasionally collide. This is what substantiates the sharded-lock
+// design over a single global mutex guarding the index.
+func BenchmarkSlicesAddParallel(b *testing.B) {
+    s := NewSlices(60)
+    var nextWorker int64
+    b.RunParallel(func(pb *testing.PB) {
+        source := fmt.Sprintf("host%d", atomic.AddInt64(&nextWorker, 1))
+        i := 0
+        for pb.Next() {
+            s.Add(NewMessage(source, "requests.count", i))
+            i++
+        }
+    })
+}
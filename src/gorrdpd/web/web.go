@@ -0,0 +1,17 @@
+package web
+
+import (
+    "http"
+    "gorrdpd/config"
+)
+
+// Start serves the read-only HTTP status endpoint used for health
+// checks and ad-hoc inspection. It never returns.
+func Start() {
+    http.HandleFunc("/status", statusHandler)
+    http.ListenAndServe(config.Global.WebListen, nil)
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+    w.Write([]uint8("ok\n"))
+}
@@ -0,0 +1,122 @@
+package writers
+
+import (
+    "fmt"
+    "net"
+    "os"
+    "sync"
+    "time"
+    "gorrdpd/types"
+)
+
+const (
+    graphiteMinBackoff = 1e9  // 1 second, in ns
+    graphiteMaxBackoff = 30e9 // 30 seconds, in ns
+)
+
+// Graphite forwards rolled-up sample sets to a Carbon line-receiver,
+// formatting each one as "metric.path value timestamp\n". The TCP
+// connection is held open across rollups and reconnected with
+// exponential backoff whenever the peer goes away. connect never
+// blocks the caller waiting out the backoff itself: it redials at most
+// once per backoff window and returns a stale error immediately the
+// rest of the time, so a dead Carbon endpoint fails writes quickly
+// instead of stalling the whole rollup (and every other writer sharing
+// its goroutine) for the length of the backoff.
+//
+// Write is called from whichever goroutine is running a rollup, and
+// more than one can: the dumper's own tick, and a SIGHUP-triggered
+// flush from the signal handler, can both land on the same *Graphite*
+// instance at once. mutex guards conn/backoff/lastAttempt so those two
+// never interleave a dial/write/reconnect against each other.
+type Graphite struct {
+    Address string
+
+    mutex       sync.Mutex
+    conn        net.Conn
+    backoff     int64
+    lastAttempt int64
+}
+
+func NewGraphite(address string) *Graphite {
+    return &Graphite{Address: address, backoff: graphiteMinBackoff}
+}
+
+func (g *Graphite) Write(set *types.SampleSet) os.Error {
+    metric := set.Name
+    if set.Source != "" {
+        metric = set.Source + "." + set.Name
+    }
+    line := fmt.Sprintf("%s %d %d\n", metric, rollupValue(set), set.Timestamp)
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+    return g.send(line)
+}
+
+// Close releases the underlying TCP connection, if one is open. It is
+// called when a config reload replaces this writer, so the old
+// connection to Carbon doesn't leak.
+func (g *Graphite) Close() os.Error {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+    if g.conn == nil {
+        return nil
+    }
+    err := g.conn.Close()
+    g.conn = nil
+    return err
+}
+
+// send assumes the caller holds g.mutex.
+func (g *Graphite) send(line string) os.Error {
+    if g.conn == nil {
+        if err := g.connect(); err != nil {
+            return err
+        }
+    }
+
+    if _, err := g.conn.Write([]uint8(line)); err != nil {
+        g.conn.Close()
+        g.conn = nil
+        return err
+    }
+    return nil
+}
+
+// connect redials at most once per backoff window. A failed dial no
+// longer sleeps inline: it just widens the window and returns the
+// error, so a caller working through a batch of sample sets against a
+// dead endpoint fails every remaining one in that batch immediately
+// instead of paying the backoff delay per sample set. Assumes the
+// caller holds g.mutex.
+func (g *Graphite) connect() os.Error {
+    now := time.Seconds()
+    if now-g.lastAttempt < g.backoff/1e9 {
+        return os.NewError("graphite: " + g.Address + " still in backoff window")
+    }
+    g.lastAttempt = now
+
+    conn, err := net.Dial("tcp", "", g.Address)
+    if err != nil {
+        g.backoff *= 2
+        if g.backoff > graphiteMaxBackoff {
+            g.backoff = graphiteMaxBackoff
+        }
+        return err
+    }
+
+    g.conn = conn
+    g.backoff = graphiteMinBackoff
+    return nil
+}
+
+func init() {
+    Register("graphite", func(options map[string]interface{}) (Writer, os.Error) {
+        address, _ := options["address"].(string)
+        if address == "" {
+            return nil, os.NewError("writers: graphite requires an \"address\" option")
+        }
+        return NewGraphite(address), nil
+    })
+}
@@ -0,0 +1,57 @@
+package writers
+
+import (
+    "json"
+    "os"
+    "gorrdpd/types"
+)
+
+// JSONFile appends each rolled-up sample set as a single line of JSON
+// to an append-only file, configured via the "path" option.
+type JSONFile struct {
+    Path string
+}
+
+type jsonSample struct {
+    Source    string "source"
+    Name      string "name"
+    Type      string "type"
+    Value     int    "value"
+    Timestamp int64  "timestamp"
+}
+
+func NewJSONFile(path string) *JSONFile {
+    return &JSONFile{Path: path}
+}
+
+func (w *JSONFile) Write(set *types.SampleSet) os.Error {
+    file, err := os.Open(w.Path, os.O_WRONLY|os.O_CREAT|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    encoded, err := json.Marshal(jsonSample{
+        Source:    set.Source,
+        Name:      set.Name,
+        Type:      set.Type,
+        Value:     rollupValue(set),
+        Timestamp: set.Timestamp,
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = file.Write(append(encoded, '\n'))
+    return err
+}
+
+func init() {
+    Register("jsonfile", func(options map[string]interface{}) (Writer, os.Error) {
+        p, _ := options["path"].(string)
+        if p == "" {
+            return nil, os.NewError("writers: jsonfile requires a \"path\" option")
+        }
+        return NewJSONFile(p), nil
+    })
+}
@@ -0,0 +1,44 @@
+package writers
+
+import (
+    "os"
+    "gorrdpd/config"
+)
+
+// Factory builds a Writer from the options given in its "[writer]"
+// config block.
+type Factory func(options map[string]interface{}) (Writer, os.Error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a writer factory available under name for use in the
+// config file's "[writer]" blocks. It is meant to be called from the
+// init() of the package defining the writer, alongside the built-in
+// quartiles/count/graphite/jsonfile/stdout registrations.
+func Register(name string, factory Factory) {
+    factories[name] = factory
+}
+
+// New instantiates the writer registered as name with the given
+// options.
+func New(name string, options map[string]interface{}) (Writer, os.Error) {
+    factory, found := factories[name]
+    if !found {
+        return nil, os.NewError("writers: no writer registered as \"" + name + "\"")
+    }
+    return factory(options)
+}
+
+// BuildAll instantiates one writer per entry in configs, in order,
+// stopping at the first error.
+func BuildAll(configs []*config.WriterConfig) ([]Writer, os.Error) {
+    built := make([]Writer, 0, len(configs))
+    for _, c := range configs {
+        writer, err := New(c.Name, c.Options)
+        if err != nil {
+            return nil, err
+        }
+        built = append(built, writer)
+    }
+    return built, nil
+}
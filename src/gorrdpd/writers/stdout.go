@@ -0,0 +1,22 @@
+package writers
+
+import (
+    "fmt"
+    "os"
+    "gorrdpd/types"
+)
+
+// Stdout writes each rolled-up sample set to the process's standard
+// output; mainly useful for sanity-checking a config interactively.
+type Stdout struct{}
+
+func (w *Stdout) Write(set *types.SampleSet) os.Error {
+    _, err := fmt.Printf("%s.%s %d %d\n", set.Source, set.Name, rollupValue(set), set.Timestamp)
+    return err
+}
+
+func init() {
+    Register("stdout", func(options map[string]interface{}) (Writer, os.Error) {
+        return &Stdout{}, nil
+    })
+}
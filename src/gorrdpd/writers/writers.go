@@ -0,0 +1,127 @@
+package writers
+
+import (
+    "fmt"
+    "os"
+    "path"
+    "gorrdpd/config"
+    "gorrdpd/types"
+)
+
+// Writer knows how to persist a single rolled-up sample set, e.g. to an
+// RRD file or a remote TSDB.
+type Writer interface {
+    Write(set *types.SampleSet) os.Error
+}
+
+// Closeable is implemented by writers that hold a resource worth
+// releasing when they stop being active, e.g. Graphite's open TCP
+// connection to Carbon. A config reload that replaces the writer set
+// closes every outgoing writer implementing this, rather than just
+// dropping the reference and leaking whatever it held.
+type Closeable interface {
+    Close() os.Error
+}
+
+// CloseAll closes every writer in writers that implements Closeable,
+// logging rather than propagating any error so one writer's Close
+// failure doesn't stop the rest from being closed.
+func CloseAll(writers []Writer) {
+    for _, writer := range writers {
+        if closer, ok := writer.(Closeable); ok {
+            if err := closer.Close(); err != nil {
+                fmt.Fprintf(os.Stderr, "writers: close failed: %s\n", err)
+            }
+        }
+    }
+}
+
+// Rollup writes a single sample set through writer. Errors are logged
+// rather than propagated, so one failing writer cannot block the
+// others or stall the dumper goroutine.
+func Rollup(writer Writer, set *types.SampleSet) {
+    if err := writer.Write(set); err != nil {
+        fmt.Fprintf(os.Stderr, "writers: rollup failed: %s\n", err)
+    }
+}
+
+// BatchRollup writes every sample set in sets through writer.
+func BatchRollup(writer Writer, sets []*types.SampleSet) {
+    for _, set := range sets {
+        Rollup(writer, set)
+    }
+}
+
+// rollupValue reduces a sample set's collected values to the single
+// number a writer forwards downstream. Counters are summed, gauges
+// report their last value, and everything else (plain gorrdpd samples,
+// timers) is averaged.
+func rollupValue(set *types.SampleSet) int {
+    if len(set.Values) == 0 {
+        return 0
+    }
+    switch set.Type {
+    case "c":
+        sum := 0
+        for _, v := range set.Values {
+            sum += v
+        }
+        return sum
+    case "g":
+        return set.Values[len(set.Values)-1]
+    default:
+        return average(set.Values)
+    }
+}
+
+func average(values []int) int {
+    sum := 0
+    for _, v := range values {
+        sum += v
+    }
+    return sum / len(values)
+}
+
+// Quartiles writes rollupValue's reduction of each sample set (summed
+// for counters, last-value for gauges, averaged otherwise) to an RRD
+// tree rooted at config.Global.DataDir.
+type Quartiles struct{}
+
+func (w *Quartiles) Write(set *types.SampleSet) os.Error {
+    return appendSample(set, rollupValue(set))
+}
+
+// Count writes the number of samples collected for each source+name
+// pair to an RRD tree rooted at config.Global.DataDir.
+type Count struct{}
+
+func (w *Count) Write(set *types.SampleSet) os.Error {
+    return appendSample(set, len(set.Values))
+}
+
+func init() {
+    Register("quartiles", func(options map[string]interface{}) (Writer, os.Error) {
+        return &Quartiles{}, nil
+    })
+    Register("count", func(options map[string]interface{}) (Writer, os.Error) {
+        return &Count{}, nil
+    })
+}
+
+// appendSample appends "timestamp value\n" to the RRD file for
+// set.Source/set.Name, creating the containing directory on first use.
+func appendSample(set *types.SampleSet, value int) os.Error {
+    dir := path.Join(config.Global.DataDir, set.Source)
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return err
+    }
+
+    file, err := os.Open(path.Join(dir, set.Name+".rrd"), os.O_WRONLY|os.O_CREAT|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    _, err = fmt.Fprintf(file, "%d %d\n", set.Timestamp, value)
+    return err
+}